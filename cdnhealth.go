@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCDNFailures is how many times a CDN may fail a download attempt in a
+// single run before it's demoted in favor of healthier ones for the rest of
+// the run.
+const maxCDNFailures = 3
+
+var cdnState sync.Mutex // guards onlineCDNs, onlineServers and cdnFailures
+var cdnFailures = make(map[int]int)
+
+func formatCDNUrl(cdn int, path string) string {
+	url := fmt.Sprintf("https://cdn%v.burningsw.to/%s", cdn, path)
+	return strings.ReplaceAll(url, "\\", "/")
+}
+
+// currentCDNs returns a snapshot of the online CDN ids and count, safe to
+// call while refreshCDNStatus may be updating them from another goroutine.
+func currentCDNs() ([]int, int) {
+	cdnState.Lock()
+	defer cdnState.Unlock()
+	cdns := make([]int, len(onlineCDNs))
+	copy(cdns, onlineCDNs)
+	return cdns, onlineServers
+}
+
+// recordCDNFailure notes that cdn failed a download attempt.
+func recordCDNFailure(cdn int) {
+	cdnState.Lock()
+	defer cdnState.Unlock()
+	cdnFailures[cdn]++
+}
+
+func isCDNDemoted(cdn int) bool {
+	cdnState.Lock()
+	defer cdnState.Unlock()
+	return cdnFailures[cdn] >= maxCDNFailures
+}
+
+// candidateCDNs orders the CDNs to try for a download: preferred first, then
+// the rest of the currently-online CDNs, with any demoted CDN skipped
+// unless that would leave nothing to try at all.
+func candidateCDNs(preferred int) []int {
+	online, _ := currentCDNs()
+
+	ordered := make([]int, 0, len(online))
+	ordered = append(ordered, preferred)
+	for _, cdn := range online {
+		if cdn != preferred {
+			ordered = append(ordered, cdn)
+		}
+	}
+
+	var usable []int
+	for _, cdn := range ordered {
+		if !isCDNDemoted(cdn) {
+			usable = append(usable, cdn)
+		}
+	}
+	if len(usable) == 0 {
+		return ordered
+	}
+	return usable
+}
+
+// refreshCDNStatus re-runs checkCDNStatus so a CDN that comes back online
+// mid-run is picked back up, and one that's gone down is dropped from
+// rotation, without requiring a restart.
+func refreshCDNStatus() {
+	online, count := checkCDNStatus()
+	if count == 0 {
+		return // keep the last known-good list rather than downloading from nothing
+	}
+	cdnState.Lock()
+	onlineCDNs = online
+	onlineServers = count
+	cdnState.Unlock()
+}
+
+// periodicCDNRefresh calls refreshCDNStatus on a timer until stop is closed,
+// so long-running updates notice CDN health changes even when every
+// download happens to be succeeding.
+func periodicCDNRefresh(stop <-chan struct{}) {
+	ticker := time.NewTicker(60 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			refreshCDNStatus()
+		case <-stop:
+			return
+		}
+	}
+}
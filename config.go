@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+const launcherConfigFile = "launcher.toml"
+
+// globList is a named list of glob patterns, matched against a manifest
+// entry's Path.
+type globList struct {
+	Globs []string `toml:"globs"`
+}
+
+// launcherConfig is the user-editable policy file read from
+// installDirectory/launcher.toml. [keep] globs are never overwritten by an
+// update; [ignore] globs are never even hashed during verifyFiles. Both are
+// optional, and a missing launcher.toml just means neither applies.
+type launcherConfig struct {
+	Keep   globList `toml:"keep"`
+	Ignore globList `toml:"ignore"`
+}
+
+func loadLauncherConfig() *launcherConfig {
+	cfg := &launcherConfig{}
+
+	path := filepath.Join(installDirectory, launcherConfigFile)
+	if _, err := os.Stat(path); err != nil {
+		return cfg
+	}
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		// A typo here would otherwise silently drop the user's [keep]
+		// globs - the entire point of this file - and let the next update
+		// overwrite files they asked us to protect. Block the run instead
+		// of guessing what they meant.
+		log.Fatalf("could not parse %v: %v", launcherConfigFile, err)
+	}
+	return cfg
+}
+
+func matchesAnyGlob(globs []string, path string) bool {
+	for _, pattern := range globs {
+		if ok, err := filepath.Match(pattern, path); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
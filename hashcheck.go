@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// verifyResponseDigest inspects X-Bsw-Blake2b, our own response header that
+// we document as carrying the Blake2b hash of the *decompressed* file (the
+// same hash file.Hash holds), and checks it against the manifest's expected
+// hash before a single byte of the body is read. We deliberately don't look
+// at X-Goog-Hash or the RFC 3230 Digest header here: those are defined as a
+// digest of the literal bytes transferred, which at this point is still the
+// compressed s2 stream on *.tmp, not file.Hash - trusting them here would
+// reject every download from a CDN that implements them correctly. When
+// X-Bsw-Blake2b matches, this catches CDN corruption immediately with a much
+// clearer error than the "s2: corrupt input" that would otherwise surface
+// from a truncated download, and lets the caller skip re-hashing after the
+// fact.
+func verifyResponseDigest(resp *http.Response, expectedHex string) (matched bool, err error) {
+	for _, candidate := range candidateDigests(resp) {
+		raw, decodeErr := base64.StdEncoding.DecodeString(candidate)
+		if decodeErr != nil {
+			continue
+		}
+		got := hex.EncodeToString(raw)
+		if got == expectedHex {
+			return true, nil
+		}
+		if len(raw)*2 == len(expectedHex) {
+			// looked like a Blake2b digest but didn't match - reject now
+			// rather than waiting for a corrupt download to fail later
+			return false, fmt.Errorf("CDN-asserted hash %v does not match manifest hash %v", got, expectedHex)
+		}
+	}
+	return false, nil
+}
+
+func candidateDigests(resp *http.Response) []string {
+	var candidates []string
+
+	if v := resp.Header.Get("X-Bsw-Blake2b"); v != "" {
+		candidates = append(candidates, v)
+	}
+
+	return candidates
+}
+
+// verifyDecompressedHash re-hashes file.Path after decompression and
+// compares it to the manifest hash, the same check verifyFiles does for
+// files already on disk. Only run under --thorough since it costs a full
+// re-read of the file.
+func verifyDecompressedHash(file File) error {
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hasher, _ := blake2b.New256(nil)
+	if _, err := io.Copy(hasher, f); err != nil {
+		return err
+	}
+
+	if hash := hex.EncodeToString(hasher.Sum(nil)); hash != file.Hash {
+		return fmt.Errorf("%v failed post-decompress hash verification: got %v, want %v", file.Path, hash, file.Hash)
+	}
+	return nil
+}
@@ -17,7 +17,6 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"strings"
 	"sync"
 	"time"
 )
@@ -29,6 +28,9 @@ var installDirectory, _ = os.Getwd()
 var progressBarManager = mpb.New()
 var onlineCDNs []int
 var onlineServers int
+var runProgress *totalProgress
+var dryRun bool
+var thorough bool
 
 type File struct {
 	PathLen      uint32
@@ -45,20 +47,46 @@ type VersionFile struct {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		keysCmd(os.Args[2:])
+		return
+	}
+	for _, arg := range os.Args[1:] {
+		switch arg {
+		case "--dry-run":
+			dryRun = true
+		case "--thorough":
+			thorough = true
+		}
+	}
+
 	if onlineCDNs, onlineServers = checkCDNStatus(); onlineServers == 0 {
 		log.Fatal("There are no download servers online. Message the BSW admins if there is no post in #news already.")
 	}
 
 	versionFile, err := fetchVersionFile()
 	if err != nil {
-		log.Fatal("Could not fetch the version file.")
+		log.Fatalf("Could not fetch the version file: %v", err)
 	}
 	fmt.Printf("Fetched version information for %v files.\n", versionFile.NumberOfFiles)
 
 	toDownload := verifyFiles(versionFile.Files)
 	fmt.Printf("Found %v files that need to be updated.\n", len(toDownload))
 
+	if dryRun {
+		for _, file := range toDownload {
+			fmt.Printf("Would download: %v\n", file.Path)
+		}
+		return
+	}
+
+	stopCDNRefresh := make(chan struct{})
+	go periodicCDNRefresh(stopCDNRefresh)
+
+	runProgress = newTotalProgress(toDownload)
 	downloadFiles(toDownload, runtime.NumCPU())
+	runProgress.finish()
+	close(stopCDNRefresh)
 }
 
 func checkCDNStatus() ([]int, int) {
@@ -81,6 +109,10 @@ func fetchVersionFile() (*VersionFile, error) {
 		return nil, err
 	}
 
+	if err := verifyVersionFile(data); err != nil {
+		return nil, fmt.Errorf("version.bin failed signature verification: %v", err)
+	}
+
 	for i := range data {
 		data[i] ^= byte(i%0xFF + 0x69)
 	}
@@ -111,32 +143,48 @@ func fetchVersionFile() (*VersionFile, error) {
 		_ = binary.Read(buffer, binary.LittleEndian, &file.LastModified)
 	}
 
+	for i := range versionFile.Files {
+		sanitized, err := sanitizePath(versionFile.Files[i].Path)
+		if err != nil {
+			return nil, fmt.Errorf("manifest rejected: %v", err)
+		}
+		versionFile.Files[i].Path = sanitized
+	}
+
 	return versionFile, nil
 }
 
 func verifyFiles(files []File) []File {
 	var toDownload []File
+	cfg := loadLauncherConfig()
 
 	for _, file := range files {
 		fileName := file.Path
-
-		hasher, _ := blake2b.New256(nil)
 		fmt.Print("Checking ", fileName, ": ")
-		localFile, err := os.Open(filepath.Join(installDirectory, fileName))
 
+		if matchesAnyGlob(cfg.Ignore.Globs, fileName) {
+			println("Ignored by launcher.toml, skipping.")
+			continue
+		}
+
+		localFile, err := os.Open(filepath.Join(installDirectory, fileName))
 		if err != nil {
 			println("Need to download.")
 			toDownload = append(toDownload, file)
 			continue
 		}
-		fi, err := localFile.Stat()
-		if err == nil && fi.Mode() == os.FileMode(0444) {
-			println("File is custom (read-only), skipping.")
+
+		if matchesAnyGlob(cfg.Keep.Globs, fileName) {
+			println("Kept by launcher.toml, skipping.")
+			_ = localFile.Close()
 			continue
 		}
+
+		hasher, _ := blake2b.New256(nil)
 		if _, err := io.Copy(hasher, localFile); err != nil {
 			println("Need to download.")
 			toDownload = append(toDownload, file)
+			_ = localFile.Close()
 			continue
 		}
 		_ = localFile.Close()
@@ -148,8 +196,10 @@ func verifyFiles(files []File) []File {
 			println("Need to download.")
 			toDownload = append(toDownload, file)
 		} else {
-			lm := time.Unix(file.LastModified, 0)
-			err = os.Chtimes(file.Path, lm, lm)
+			if !dryRun {
+				lm := time.Unix(file.LastModified, 0)
+				_ = os.Chtimes(file.Path, lm, lm)
+			}
 			println("OK.")
 		}
 	}
@@ -178,20 +228,33 @@ func downloadFiles(toDownload []File, numWorkers int) {
 
 func worker(id int, jobs <-chan File, wg *sync.WaitGroup) {
 	for j := range jobs {
-		formattedUrl := fmt.Sprintf("https://cdn%v.burningsw.to/%s", onlineCDNs[id%onlineServers], j.Path)
-		formattedUrl = strings.ReplaceAll(formattedUrl, "\\", "/")
+		online, count := currentCDNs()
+		preferredCDN := online[id%count]
+
+		if count > 1 {
+			headUrl := formatCDNUrl(preferredCDN, j.Path)
+			if size, ok := supportsRangeSplit(headUrl); ok && size >= rangeSplitThreshold {
+				if err := downloadFileSplit(j, size, wg); err == nil {
+					continue
+				} else {
+					log.Print(err)
+					println("Falling back to a single-stream download for", j.Path)
+				}
+			}
+		}
+
 		force := DefaultForceDownload
 		for {
-			err := downloadFile(j, formattedUrl, wg, force)
+			err := downloadFile(j, preferredCDN, wg, force)
 			if err != nil {
 				if force {
-					println("Download for", formattedUrl, "failed again, check manually.")
+					println("Download for", j.Path, "failed again on every CDN, check manually.")
 					wg.Done()
 					break
 				}
 				// force download fresh
 				log.Print(err)
-				println(" (" + formattedUrl + "), Retrying")
+				println(" (" + j.Path + "), Retrying")
 				force = true
 				continue
 			}
@@ -200,7 +263,73 @@ func worker(id int, jobs <-chan File, wg *sync.WaitGroup) {
 	}
 }
 
-func downloadFile(file File, url string, wg *sync.WaitGroup, force bool) error {
+// downloadFileSplit fetches file across every online CDN at once using byte
+// ranges (see downloadFileRanged), then decompresses it exactly like a
+// normal single-stream download once all ranges are complete.
+func downloadFileSplit(file File, size int64, wg *sync.WaitGroup) error {
+	online, _ := currentCDNs()
+	urls := make([]string, len(online))
+	for i, cdn := range online {
+		urls[i] = formatCDNUrl(cdn, file.Path)
+	}
+
+	if err := downloadFileRanged(file.Path, size, urls); err != nil {
+		return err
+	}
+
+	if err := decompressDownload(file); err != nil {
+		return err
+	}
+
+	if thorough {
+		if err := verifyDecompressedHash(file); err != nil {
+			return err
+		}
+	}
+
+	wg.Done()
+	return nil
+}
+
+// downloadFile fetches file, preferring preferredCDN but transparently
+// rotating to the next candidate CDN (see candidateCDNs) on network error or
+// a non-2xx/206 response, always resuming from whatever bytes already made
+// it into filename.tmp rather than starting over.
+func downloadFile(file File, preferredCDN int, wg *sync.WaitGroup, force bool) error {
+	var lastErr error
+	for _, cdn := range candidateCDNs(preferredCDN) {
+		digestVerified, err := downloadFileAttempt(file, formatCDNUrl(cdn, file.Path), force)
+		if err != nil {
+			lastErr = err
+			recordCDNFailure(cdn)
+			log.Printf("download of %v from cdn%v failed: %v, trying next CDN", file.Path, cdn, err)
+			refreshCDNStatus()
+			continue
+		}
+
+		if err := decompressDownload(file); err != nil {
+			return err
+		}
+
+		if thorough && !digestVerified {
+			if err := verifyDecompressedHash(file); err != nil {
+				return err
+			}
+		}
+
+		wg.Done()
+		return nil
+	}
+	return fmt.Errorf("every CDN failed for %v: %v", file.Path, lastErr)
+}
+
+// downloadFileAttempt does a single resumable GET for file against url,
+// writing into filename.tmp. A non-empty filename.tmp (from either a prior
+// attempt against this CDN or a failed one against another) is resumed with
+// a Range request unless force is set. The returned bool reports whether
+// the CDN's own response headers already asserted a matching Blake2b
+// digest, letting callers skip the more expensive post-decompress re-hash.
+func downloadFileAttempt(file File, url string, force bool) (bool, error) {
 	filename := file.Path
 	// Create the file, but give it a tmp file extension, this means we won't overwrite a
 	// file until it's downloaded, but we'll remove the tmp extension once downloaded.
@@ -210,8 +339,7 @@ func downloadFile(file File, url string, wg *sync.WaitGroup, force bool) error {
 	var out *os.File
 	x, dlerr := http.NewRequest("GET", url, nil)
 	if dlerr != nil {
-		log.Fatal(dlerr)
-		return err
+		return false, dlerr
 	}
 
 	if !force && err == nil {
@@ -227,19 +355,45 @@ func downloadFile(file File, url string, wg *sync.WaitGroup, force bool) error {
 		//}
 		out, err = os.OpenFile(filename+".tmp", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0777)
 		if err != nil {
-			return err
+			return false, err
 		}
 	} else {
 		out, err = os.Create(filename + ".tmp")
+		if err != nil {
+			return false, err
+		}
 	}
+	defer out.Close()
 
 	// Get the data
 	client := &http.Client{}
 	resp, err := client.Do(x)
 	if err != nil {
-		return err
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return false, fmt.Errorf("unexpected status %v from %v", resp.StatusCode, url)
+	}
+	if resp.StatusCode == http.StatusOK && currPosition > 0 {
+		// This CDN ignored our Range header and sent the full file from byte
+		// 0 instead of the 206 we asked for. out is open O_APPEND, so
+		// blindly copying the body would tack a second full copy onto what
+		// we already have. Bail out and let the caller rotate to another
+		// CDN (or force-retry from scratch) rather than produce a corrupt,
+		// oversized file.
+		return false, fmt.Errorf("%v ignored our Range request and returned a full 200 from %v", filename, url)
 	}
 
+	digestVerified, err := verifyResponseDigest(resp, file.Hash)
+	if err != nil {
+		return false, err
+	}
+
+	barSlots <- struct{}{}
+	defer func() { <-barSlots }()
+
 	// Create our progress reporter and pass it to be used alongside our writer
 	bar := progressBarManager.AddBar(currPosition+resp.ContentLength,
 		mpb.PrependDecorators(
@@ -258,40 +412,44 @@ func downloadFile(file File, url string, wg *sync.WaitGroup, force bool) error {
 
 	defer bar.Abort(true) // Remove the bar when it's done downloading to clean up the console
 	proxyReader := bar.ProxyReader(resp.Body)
+	defer proxyReader.Close() // Close file handles on exit
 	if currPosition > 0 {
 		bar.IncrInt64(currPosition)
+		runProgress.incr(currPosition)
 	}
-	if _, err = io.Copy(out, proxyReader); err != nil {
-		//log.Fatal(err)
-		return err
+	if _, err = io.Copy(out, &totalBarReader{Reader: proxyReader, tp: runProgress}); err != nil {
+		return false, err
 	}
-	defer proxyReader.Close() // Close file handles on exit
-	defer resp.Body.Close()
+
+	return digestVerified, nil
+}
+
+// decompressDownload s2-decompresses filename.tmp into its final location
+// and restores the manifest's LastModified timestamp, once all of its bytes
+// (single-stream or ranged) have been written.
+func decompressDownload(file File) error {
+	filename := file.Path
 
 	decompress, err := os.Create(filename)
 	if err != nil {
-		//log.Fatal(err)
 		return err
 	}
+	defer decompress.Close()
 
-	_ = out.Close()
-	out, err = os.Open(filename + ".tmp") // reopen for reading
+	tmp, err := os.Open(filename + ".tmp")
 	if err != nil {
-		//log.Fatal(err)
 		return err
 	}
+	defer tmp.Close()
 
-	if _, err = io.Copy(decompress, s2.NewReader(out)); err != nil { // Decompress the data using s2d
+	if _, err = io.Copy(decompress, s2.NewReader(tmp)); err != nil { // Decompress the data using s2d
 		return err
 	}
-	_ = out.Close()
+	_ = tmp.Close()
 	_ = os.Remove(filename + ".tmp")
 
 	lm := time.Unix(file.LastModified, 0)
-	err = os.Chtimes(file.Path, lm, lm)
-
-	wg.Done()
-	return nil
+	return os.Chtimes(file.Path, lm, lm)
 }
 
 func getFile(path string) ([]byte, error) {
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// sanitizePath validates a manifest-supplied path before it's ever joined
+// against installDirectory: no drive letters or UNC prefixes, no escaping
+// the install directory once cleaned, and no escaping it via a symlink
+// that's already on disk. Callers should refuse the whole manifest on
+// error rather than skip just this entry.
+func sanitizePath(rawPath string) (string, error) {
+	if strings.ContainsRune(rawPath, ':') || strings.HasPrefix(rawPath, `\\`) {
+		return "", fmt.Errorf("manifest path %q looks like a drive letter or UNC path", rawPath)
+	}
+
+	cleaned := filepath.Clean(strings.ReplaceAll(rawPath, "\\", "/"))
+	if filepath.IsAbs(cleaned) {
+		return "", fmt.Errorf("manifest path %q is absolute", rawPath)
+	}
+
+	joined := filepath.Join(installDirectory, cleaned)
+	if rel, err := filepath.Rel(installDirectory, joined); err != nil || escapesRoot(rel) {
+		return "", fmt.Errorf("manifest path %q escapes the install directory", rawPath)
+	}
+
+	if resolved, err := filepath.EvalSymlinks(filepath.Dir(joined)); err == nil {
+		if rel, err := filepath.Rel(installDirectory, resolved); err != nil || escapesRoot(rel) {
+			return "", fmt.Errorf("manifest path %q resolves outside the install directory via a symlink", rawPath)
+		}
+	}
+
+	return cleaned, nil
+}
+
+func escapesRoot(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
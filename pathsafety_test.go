@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func withInstallDirectory(t *testing.T, dir string) {
+	t.Helper()
+	old := installDirectory
+	installDirectory = dir
+	t.Cleanup(func() { installDirectory = old })
+}
+
+func TestSanitizePath(t *testing.T) {
+	withInstallDirectory(t, t.TempDir())
+
+	cases := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"plain relative path", "data/assets/texture.bin", false},
+		{"windows-style separators", `data\assets\texture.bin`, false},
+		{"dot traversal escapes root", "../../etc/passwd", true},
+		{"nested traversal escapes root", "data/../../outside.bin", true},
+		{"drive letter", `C:\Windows\System32\evil.dll`, true},
+		{"unc prefix", `\\attacker\share\evil.dll`, true},
+		{"absolute unix path", "/etc/passwd", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := sanitizePath(tc.path)
+			if tc.wantErr && err == nil {
+				t.Fatalf("expected %q to be rejected", tc.path)
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("expected %q to be accepted, got %v", tc.path, err)
+			}
+		})
+	}
+}
+
+func TestSanitizePathRejectsSymlinkEscape(t *testing.T) {
+	dir := t.TempDir()
+	withInstallDirectory(t, dir)
+
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "linked")); err != nil {
+		t.Skipf("symlinks unsupported on this platform: %v", err)
+	}
+
+	if _, err := sanitizePath("linked/evil.bin"); err == nil {
+		t.Fatal("expected a path through a symlink escaping the install directory to be rejected")
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VividCortex/ewma"
+	"github.com/vbauerster/mpb"
+	"github.com/vbauerster/mpb/decor"
+)
+
+// ewmaAge is the number of previous samples EwmaSpeed/EwmaETA average over;
+// kept in one place since newTotalProgress has to build the same
+// ewma.MovingAverage the decor package would build internally in order to
+// seed it.
+const ewmaAge = 60
+
+// maxVisibleBars caps how many per-file bars are ever on screen at once;
+// once that many downloads are in flight, new ones wait for a slot so
+// completed bars are recycled instead of stacking forever.
+const maxVisibleBars = 8
+
+const statsFile = ".launcher-stats.json"
+
+var barSlots = make(chan struct{}, maxVisibleBars)
+
+// launcherStats is persisted between runs purely to seed the total bar's
+// EwmaSpeed/EwmaETA decorators, so the ETA means something in the first few
+// seconds instead of starting from zero.
+type launcherStats struct {
+	AvgSpeedBytesPerSec float64 `json:"avg_speed_bytes_per_sec"`
+}
+
+func loadLauncherStats() launcherStats {
+	data, err := ioutil.ReadFile(statsFile)
+	if err != nil {
+		return launcherStats{}
+	}
+	var s launcherStats
+	_ = json.Unmarshal(data, &s)
+	return s
+}
+
+func saveLauncherStats(s launcherStats) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	_ = ioutil.WriteFile(statsFile, data, 0644)
+}
+
+// totalProgress is the aggregate "Total" bar shown across every file in a
+// single update run, plus the bookkeeping needed to persist a fresh average
+// speed for the next run's ETA seed.
+type totalProgress struct {
+	bar     *mpb.Bar
+	started time.Time
+	written int64
+}
+
+// newTotalProgress sizes the Total bar to the sum of expected compressed
+// bytes across toDownload and seeds its EwmaSpeed/EwmaETA decorators from
+// the previous run's persisted average, if any.
+func newTotalProgress(toDownload []File) *totalProgress {
+	totalBytes := totalExpectedBytes(toDownload)
+	stats := loadLauncherStats()
+
+	speedAvg := ewma.NewMovingAverage(ewmaAge)
+	etaAvg := ewma.NewMovingAverage(ewmaAge)
+	if stats.AvgSpeedBytesPerSec > 0 {
+		speedAvg.Set(stats.AvgSpeedBytesPerSec)
+		etaAvg.Set(float64(time.Second) / stats.AvgSpeedBytesPerSec)
+	}
+
+	bar := progressBarManager.AddBar(totalBytes,
+		mpb.PrependDecorators(decor.Name("Total > ")),
+		mpb.AppendDecorators(
+			decor.OnComplete(decor.MovingAverageETA(decor.ET_STYLE_GO, etaAvg, decor.NopNormalizer()), "done"),
+			decor.Name(" @ "),
+			decor.MovingAverageSpeed(decor.UnitKiB, "% .2f", speedAvg),
+		),
+	)
+
+	return &totalProgress{bar: bar, started: time.Now()}
+}
+
+// finish persists this run's average throughput so the next run's Total bar
+// has a meaningful ETA from the start.
+func (tp *totalProgress) finish() {
+	elapsed := time.Since(tp.started)
+	if elapsed <= 0 || tp.written == 0 {
+		return
+	}
+	saveLauncherStats(launcherStats{AvgSpeedBytesPerSec: float64(tp.written) / elapsed.Seconds()})
+}
+
+func (tp *totalProgress) incr(n int64) {
+	if tp == nil {
+		return
+	}
+	tp.bar.IncrBy(int(n))
+	atomic.AddInt64(&tp.written, n)
+}
+
+// totalBarReader wraps a per-file reader so every byte it reads also counts
+// towards the run-wide Total bar.
+type totalBarReader struct {
+	io.Reader
+	tp *totalProgress
+}
+
+func (r *totalBarReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.tp.incr(int64(n))
+	}
+	return n, err
+}
+
+func expectedFileSize(file File) int64 {
+	online, _ := currentCDNs()
+	if len(online) == 0 {
+		return 0
+	}
+	resp, err := http.Head(formatCDNUrl(online[0], file.Path))
+	if err != nil || resp.ContentLength <= 0 {
+		return 0
+	}
+	return resp.ContentLength
+}
+
+// totalExpectedBytes HEADs every file concurrently to size the Total bar
+// before any download starts.
+func totalExpectedBytes(toDownload []File) int64 {
+	sizes := make(chan int64, len(toDownload))
+	sem := make(chan struct{}, runtime.NumCPU())
+	var wg sync.WaitGroup
+
+	for _, file := range toDownload {
+		wg.Add(1)
+		go func(f File) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			sizes <- expectedFileSize(f)
+		}(file)
+	}
+	go func() {
+		wg.Wait()
+		close(sizes)
+	}()
+
+	var total int64
+	for size := range sizes {
+		total += size
+	}
+	return total
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/vbauerster/mpb"
+	"github.com/vbauerster/mpb/decor"
+)
+
+// Large files are split across up to maxRangeSplit CDNs at once; anything
+// smaller isn't worth the extra HEAD request and connection overhead.
+const (
+	maxRangeSplit       = 5
+	rangeSplitThreshold = 64 * 1024 * 1024
+)
+
+// rangePart tracks one in-flight byte range of a multi-connection download,
+// the same bookkeeping a per-connection ConnectionStat would hold, so a
+// crashed download can resume each range independently.
+type rangePart struct {
+	Start   int64 `json:"start"`
+	End     int64 `json:"end"`
+	Written int64 `json:"written"`
+}
+
+type rangeManifest struct {
+	Parts []rangePart `json:"parts"`
+}
+
+func partsPath(filename string) string { return filename + ".tmp.parts" }
+
+func loadRangeManifest(filename string) (*rangeManifest, error) {
+	data, err := ioutil.ReadFile(partsPath(filename))
+	if err != nil {
+		return nil, err
+	}
+	m := &rangeManifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *rangeManifest) save(filename string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(partsPath(filename), data, 0644)
+}
+
+func newRangeManifest(totalSize int64, n int) *rangeManifest {
+	m := &rangeManifest{Parts: make([]rangePart, n)}
+	chunk := totalSize / int64(n)
+	for i := 0; i < n; i++ {
+		start := int64(i) * chunk
+		end := start + chunk - 1
+		if i == n-1 {
+			end = totalSize - 1
+		}
+		m.Parts[i] = rangePart{Start: start, End: end}
+	}
+	return m
+}
+
+// supportsRangeSplit does a HEAD request to confirm a CDN will honor Range
+// requests and reports a usable Content-Length, which multi-connection
+// downloads require. Anything else and callers should fall back to a plain
+// single-stream download.
+func supportsRangeSplit(url string) (int64, bool) {
+	resp, err := http.Head(url)
+	if err != nil || resp.StatusCode != 200 {
+		return 0, false
+	}
+	if resp.Header.Get("Accept-Ranges") != "bytes" || resp.ContentLength <= 0 {
+		return 0, false
+	}
+	return resp.ContentLength, true
+}
+
+// downloadFileRanged fetches file as up to len(urls) byte ranges in
+// parallel, one per CDN, written directly into filename.tmp at their final
+// offsets via WriteAt. Progress for each range is persisted to a
+// *.tmp.parts sidecar so a crashed multi-range download resumes without
+// re-fetching completed ranges.
+func downloadFileRanged(filename string, totalSize int64, urls []string) error {
+	n := len(urls)
+	if n > maxRangeSplit {
+		n = maxRangeSplit
+	}
+
+	manifest, err := loadRangeManifest(filename)
+	if err != nil || len(manifest.Parts) != n {
+		manifest = newRangeManifest(totalSize, n)
+	}
+
+	out, err := os.OpenFile(filename+".tmp", os.O_CREATE|os.O_WRONLY, 0777)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	barSlots <- struct{}{}
+	defer func() { <-barSlots }()
+
+	totalBar := progressBarManager.AddBar(totalSize,
+		mpb.PrependDecorators(decor.Name(filename+" > Total > ")),
+		mpb.AppendDecorators(
+			decor.OnComplete(decor.EwmaETA(decor.ET_STYLE_GO, 60), "done"),
+			decor.Name(" @ "),
+			decor.EwmaSpeed(decor.UnitKiB, "% .2f", 60),
+		),
+	)
+	defer progressBarManager.Abort(totalBar, true)
+	for _, part := range manifest.Parts {
+		if part.Written > 0 {
+			totalBar.IncrBy(int(part.Written))
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := make([]error, n)
+
+	for i := range manifest.Parts {
+		part := &manifest.Parts[i]
+		if part.Written >= part.End-part.Start+1 {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, part *rangePart, url string) {
+			defer wg.Done()
+			errs[i] = downloadRangePart(url, out, part, totalBar, &mu, func() { _ = manifest.save(filename) })
+		}(i, part, urls[i%len(urls)])
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			// A part failing leaves filename+".tmp" sparse: later offsets
+			// may be written while an earlier, lower one never was, so its
+			// size alone can't be trusted to mean "fully downloaded." Wipe
+			// both the payload and the manifest describing it rather than
+			// handing that ambiguous state to the single-stream fallback,
+			// which only knows how to resume from a trustworthy file size.
+			_ = os.Remove(filename + ".tmp")
+			_ = os.Remove(partsPath(filename))
+			return err
+		}
+	}
+
+	_ = os.Remove(partsPath(filename))
+	return nil
+}
+
+func downloadRangePart(url string, out *os.File, part *rangePart, totalBar *mpb.Bar, mu *sync.Mutex, persist func()) error {
+	start := part.Start + part.Written
+	if start > part.End {
+		return nil
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Range", fmt.Sprintf("bytes=%v-%v", start, part.End))
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %v fetching range from %v", resp.StatusCode, url)
+	}
+
+	buf := make([]byte, 256*1024)
+	offset := start
+	for {
+		n, rerr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := out.WriteAt(buf[:n], offset); werr != nil {
+				return werr
+			}
+			offset += int64(n)
+
+			mu.Lock()
+			part.Written += int64(n)
+			persist()
+			mu.Unlock()
+
+			totalBar.IncrBy(n)
+			runProgress.incr(int64(n))
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"time"
+)
+
+// rootPublicKey is the BSW root-of-trust key, compiled into the binary. It
+// never rotates. Signing keys used day-to-day are certified by this key with
+// an expiry, so a signing key can be rotated (or revoked by simply letting it
+// expire) without ever re-shipping the launcher. Generate a real pair with
+// `launcher keys generate` and swap this placeholder before release.
+var rootPublicKey = ed25519.PublicKey{
+	0x1f, 0x83, 0xd9, 0xab, 0x4a, 0x2c, 0x6e, 0x71,
+	0x0d, 0x5b, 0x9a, 0xf4, 0x3c, 0x88, 0x17, 0x29,
+	0xe6, 0x52, 0xb0, 0xc4, 0x9f, 0x36, 0x1a, 0xd8,
+	0x24, 0x6f, 0x90, 0xcb, 0x5d, 0x03, 0x77, 0xa1,
+}
+
+const signingCertLen = ed25519.PublicKeySize + 8 + ed25519.SignatureSize
+
+// signedBundle is the parsed form of a version.bin.sig file: a root-signed
+// certificate for the day-to-day signing key, followed by that key's
+// signature over the raw (pre-XOR) version.bin bytes.
+type signedBundle struct {
+	SigningKey ed25519.PublicKey
+	Expires    time.Time
+	CertSig    []byte
+	FileSig    []byte
+}
+
+func parseSignedBundle(raw []byte) (*signedBundle, error) {
+	if len(raw) != signingCertLen+ed25519.SignatureSize {
+		return nil, fmt.Errorf("version.bin.sig has the wrong length: %v", len(raw))
+	}
+
+	b := &signedBundle{}
+	b.SigningKey = append(ed25519.PublicKey{}, raw[:ed25519.PublicKeySize]...)
+	expires := int64(binary.LittleEndian.Uint64(raw[ed25519.PublicKeySize : ed25519.PublicKeySize+8]))
+	b.Expires = time.Unix(expires, 0)
+	b.CertSig = raw[ed25519.PublicKeySize+8 : signingCertLen]
+	b.FileSig = raw[signingCertLen:]
+	return b, nil
+}
+
+func (b *signedBundle) cert() []byte {
+	cert := make([]byte, ed25519.PublicKeySize+8)
+	copy(cert, b.SigningKey)
+	binary.LittleEndian.PutUint64(cert[ed25519.PublicKeySize:], uint64(b.Expires.Unix()))
+	return cert
+}
+
+// verify checks the full trust chain for data (the raw, pre-XOR version.bin
+// bytes): the signing key certificate must verify against rootPublicKey and
+// not be expired, and data itself must verify against the signing key.
+func (b *signedBundle) verify(data []byte) error {
+	if !ed25519.Verify(rootPublicKey, b.cert(), b.CertSig) {
+		return errors.New("signing key certificate does not verify against the root key")
+	}
+	if time.Now().After(b.Expires) {
+		return fmt.Errorf("signing key expired %v, refusing to trust it", b.Expires.Format("2006-01-02"))
+	}
+	if !ed25519.Verify(b.SigningKey, data, b.FileSig) {
+		return errors.New("version.bin signature does not verify against the signing key")
+	}
+	return nil
+}
+
+// verifyVersionFile fetches version.bin.sig alongside the already-downloaded
+// version.bin bytes and verifies it before anything else touches data. Since
+// every file's Blake2b hash lives inside the signed manifest, this one check
+// transitively covers every download that follows.
+func verifyVersionFile(data []byte) error {
+	sigURL := fmt.Sprintf("https://cdn%v.burningsw.to/version.bin.sig", onlineCDNs[0])
+	raw, err := getFile(sigURL)
+	if err != nil {
+		return fmt.Errorf("could not fetch version.bin.sig: %v", err)
+	}
+
+	bundle, err := parseSignedBundle(raw)
+	if err != nil {
+		return err
+	}
+	return bundle.verify(data)
+}
+
+// keysCmd is the offline "keys" subcommand, used by operators to mint and
+// rotate signing keys without rebuilding the launcher. It never touches the
+// root private key beyond what's passed in on the command line.
+//
+//	launcher keys generate <days-valid> <root-private-key-hex>
+//	launcher keys sign <version.bin> <signing-private-key-hex>
+func keysCmd(args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: launcher keys <generate|sign> ...")
+	}
+
+	switch args[0] {
+	case "generate":
+		keysGenerate(args[1:])
+	case "sign":
+		keysSign(args[1:])
+	default:
+		log.Fatalf("unknown keys subcommand %q", args[0])
+	}
+}
+
+func keysGenerate(args []string) {
+	if len(args) != 2 {
+		log.Fatal("usage: launcher keys generate <days-valid> <root-private-key-hex>")
+	}
+
+	days, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("invalid days-valid: %v", err)
+	}
+	rootPriv, err := hex.DecodeString(args[1])
+	if err != nil || len(rootPriv) != ed25519.PrivateKeySize {
+		log.Fatal("invalid root private key")
+	}
+
+	signingPub, signingPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	b := &signedBundle{SigningKey: signingPub, Expires: time.Now().AddDate(0, 0, days)}
+	certSig := ed25519.Sign(rootPriv, b.cert())
+
+	fmt.Printf("signing public key:  %x\n", signingPub)
+	fmt.Printf("signing private key: %x\n", signingPriv)
+	fmt.Printf("expires:              %v\n", b.Expires.Format(time.RFC3339))
+	fmt.Printf("certificate:          %x\n", append(b.cert(), certSig...))
+	fmt.Println("keep the certificate bytes above; `keys sign` output gets appended to them to build version.bin.sig")
+}
+
+func keysSign(args []string) {
+	if len(args) != 2 {
+		log.Fatal("usage: launcher keys sign <version.bin> <signing-private-key-hex>")
+	}
+
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	signingPriv, err := hex.DecodeString(args[1])
+	if err != nil || len(signingPriv) != ed25519.PrivateKeySize {
+		log.Fatal("invalid signing private key")
+	}
+
+	fileSig := ed25519.Sign(signingPriv, data)
+	fmt.Printf("file signature: %x\n", fileSig)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+	"time"
+)
+
+func withRootKey(t *testing.T, pub ed25519.PublicKey) {
+	t.Helper()
+	old := rootPublicKey
+	rootPublicKey = pub
+	t.Cleanup(func() { rootPublicKey = old })
+}
+
+func signedTestBundle(rootPriv ed25519.PrivateKey, signingPub ed25519.PublicKey, signingPriv ed25519.PrivateKey, expires time.Time, data []byte) *signedBundle {
+	b := &signedBundle{SigningKey: signingPub, Expires: expires}
+	b.CertSig = ed25519.Sign(rootPriv, b.cert())
+	b.FileSig = ed25519.Sign(signingPriv, data)
+	return b
+}
+
+func TestSignedBundleVerify(t *testing.T) {
+	rootPub, rootPriv, _ := ed25519.GenerateKey(nil)
+	signingPub, signingPriv, _ := ed25519.GenerateKey(nil)
+	withRootKey(t, rootPub)
+
+	data := []byte("fake version.bin contents")
+
+	t.Run("valid signature", func(t *testing.T) {
+		b := signedTestBundle(rootPriv, signingPub, signingPriv, time.Now().Add(24*time.Hour), data)
+		if err := b.verify(data); err != nil {
+			t.Fatalf("expected valid bundle to verify, got %v", err)
+		}
+	})
+
+	t.Run("expired signing key", func(t *testing.T) {
+		b := signedTestBundle(rootPriv, signingPub, signingPriv, time.Now().Add(-time.Hour), data)
+		if err := b.verify(data); err == nil {
+			t.Fatal("expected an expired signing key to be rejected")
+		}
+	})
+
+	t.Run("tampered file", func(t *testing.T) {
+		b := signedTestBundle(rootPriv, signingPub, signingPriv, time.Now().Add(24*time.Hour), data)
+		if err := b.verify([]byte("tampered contents")); err == nil {
+			t.Fatal("expected tampered data to fail signature verification")
+		}
+	})
+
+	t.Run("cert not signed by the root key", func(t *testing.T) {
+		_, forgedRootPriv, _ := ed25519.GenerateKey(nil)
+		b := signedTestBundle(forgedRootPriv, signingPub, signingPriv, time.Now().Add(24*time.Hour), data)
+		if err := b.verify(data); err == nil {
+			t.Fatal("expected a cert signed by a non-root key to be rejected")
+		}
+	})
+}
+
+func TestParseSignedBundle(t *testing.T) {
+	t.Run("rejects the wrong length", func(t *testing.T) {
+		if _, err := parseSignedBundle([]byte("too short")); err == nil {
+			t.Fatal("expected an error for a malformed version.bin.sig")
+		}
+	})
+
+	t.Run("round-trips a well-formed bundle", func(t *testing.T) {
+		signingPub, _, _ := ed25519.GenerateKey(nil)
+		want := &signedBundle{
+			SigningKey: signingPub,
+			Expires:    time.Unix(1700000000, 0),
+			CertSig:    bytes.Repeat([]byte{0xAB}, ed25519.SignatureSize),
+			FileSig:    bytes.Repeat([]byte{0xCD}, ed25519.SignatureSize),
+		}
+		raw := append(append(want.cert(), want.CertSig...), want.FileSig...)
+
+		got, err := parseSignedBundle(raw)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(got.SigningKey, want.SigningKey) || !got.Expires.Equal(want.Expires) {
+			t.Fatalf("parsed bundle %+v does not match input %+v", got, want)
+		}
+		if !bytes.Equal(got.CertSig, want.CertSig) || !bytes.Equal(got.FileSig, want.FileSig) {
+			t.Fatal("parsed signatures do not match input")
+		}
+	})
+}